@@ -0,0 +1,115 @@
+// Package binaries resolves the external tool binaries cosmosgen depends on
+// (buf itself plus its codegen plugins) on first use, installing a pinned
+// version of each into the user's cache directory instead of requiring it
+// preinstalled on PATH, so that the Go and OpenAPI generation passes of
+// `starport generate` work on a clean machine.
+//
+// Every tool pinned here ships as a Go module with its own main command, so
+// Resolve builds it with `go install <module>@<version>` rather than
+// downloading a prebuilt release asset: verification is Go's own module
+// checksum database (GOSUMDB, sum.golang.org by default), not a digest maintained
+// by hand in this file, so it can't silently drift out of date.
+//
+// ts-proto, used by the JS generation pass, ships on npm rather than as a Go
+// module and has no entry here: JS generation still requires a host Node.js
+// toolchain with protoc-gen-ts-proto preinstalled on PATH.
+package binaries
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/tendermint/starport/starport/pkg/cmdrunner"
+	"github.com/tendermint/starport/starport/pkg/cmdrunner/step"
+)
+
+// spec pins the Go module providing a binary and the version to install.
+type spec struct {
+	// module is the Go install path of the binary's main package, e.g.
+	// "github.com/bufbuild/buf/cmd/buf".
+	module  string
+	version string
+}
+
+// specs holds the pinned module and version of every binary cosmosgen
+// depends on that's distributed as an installable Go module. ts-proto isn't
+// (it ships on npm) and has no entry here; see resolvePluginPaths in
+// cosmosgen for the PATH-based fallback that covers it instead, and the
+// package doc above for what that means for JS generation.
+var specs = map[string]spec{
+	"buf": {
+		module:  "github.com/bufbuild/buf/cmd/buf",
+		version: "v1.28.1",
+	},
+	"protoc-gen-gocosmos": {
+		module:  "github.com/cosmos/gogoproto/protoc-gen-gocosmos",
+		version: "v1.7.0",
+	},
+	"protoc-gen-grpc-gateway": {
+		module:  "github.com/grpc-ecosystem/grpc-gateway/v2/protoc-gen-grpc-gateway",
+		version: "v2.19.1",
+	},
+	"protoc-gen-openapiv2": {
+		module:  "github.com/grpc-ecosystem/grpc-gateway/v2/protoc-gen-openapiv2",
+		version: "v2.19.1",
+	},
+	"protoc-gen-go-pulsar": {
+		module:  "github.com/cosmos/cosmos-proto/cmd/protoc-gen-go-pulsar",
+		version: "v1.0.0-beta.5",
+	},
+}
+
+// Resolve returns the on-disk path of the pinned build of name, installing
+// it into the user's cache directory via "go install" on first use.
+// Subsequent calls for the same name and version reuse the cached binary
+// without rebuilding it.
+func Resolve(ctx context.Context, name string) (string, error) {
+	s, ok := specs[name]
+	if !ok {
+		return "", errors.Errorf("binaries: no pinned version known for %q", name)
+	}
+
+	dir, err := cacheDir(name, s.version)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name)
+
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+
+	if err := goInstall(ctx, s.module, s.version, dir); err != nil {
+		return "", errors.Wrapf(err, "cannot install %s %s", name, s.version)
+	}
+
+	return path, nil
+}
+
+// cacheDir returns $XDG_CACHE_HOME/starport/bin/<name>/<version>/ (or its
+// platform equivalent, via os.UserCacheDir), creating it if necessary. Go
+// itself keys its build and module caches by OS/arch internally, so the
+// resulting binary is already specific to the host it was installed on.
+func cacheDir(name, version string) (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(base, "starport", "bin", name, version)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// goInstall runs "go install module@version", placing the resulting binary
+// under gobin.
+func goInstall(ctx context.Context, module, version, gobin string) error {
+	return cmdrunner.New().Run(ctx, step.New(
+		step.Exec("go", "install", module+"@"+version),
+		step.Env("GOBIN="+gobin),
+	))
+}
@@ -0,0 +1,34 @@
+package binaries
+
+import (
+	"context"
+	"testing"
+)
+
+func TestResolveUnknownBinary(t *testing.T) {
+	if _, err := Resolve(context.Background(), "does-not-exist"); err == nil {
+		t.Fatal("expected an error for a binary with no pinned version")
+	}
+}
+
+func TestCacheDirIsStableAndVersioned(t *testing.T) {
+	a, err := cacheDir("buf", "v1.28.1")
+	if err != nil {
+		t.Fatalf("cacheDir: %v", err)
+	}
+	b, err := cacheDir("buf", "v1.28.1")
+	if err != nil {
+		t.Fatalf("cacheDir: %v", err)
+	}
+	if a != b {
+		t.Fatalf("cacheDir not stable across calls: %q != %q", a, b)
+	}
+
+	other, err := cacheDir("buf", "v1.29.0")
+	if err != nil {
+		t.Fatalf("cacheDir: %v", err)
+	}
+	if a == other {
+		t.Fatalf("cacheDir did not vary by version: %q", a)
+	}
+}
@@ -0,0 +1,56 @@
+// Package cache provides a small generic content cache, used to avoid
+// re-running expensive, deterministic generation steps (such as buf codegen)
+// when their inputs haven't changed.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Cache stores values of type V keyed by an arbitrary string key.
+type Cache[V any] interface {
+	// Get returns the value stored for key, or ok == false if there's none.
+	Get(key string) (value V, ok bool, err error)
+
+	// Put stores value under key, overwriting any previous value.
+	Put(key string, value V) error
+}
+
+// fsCache is a Cache[[]byte] persisting values as files under a directory,
+// named after the SHA-256 hash of their key so arbitrary (e.g.
+// content-addressed) keys can be used.
+type fsCache struct {
+	dir string
+}
+
+// NewFS returns a Cache[[]byte] that persists entries under dir.
+func NewFS(dir string) Cache[[]byte] {
+	return fsCache{dir: dir}
+}
+
+func (c fsCache) Get(key string) ([]byte, bool, error) {
+	b, err := ioutil.ReadFile(c.path(key))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return b, true, nil
+}
+
+func (c fsCache) Put(key string, value []byte) error {
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.path(key), value, 0o644)
+}
+
+func (c fsCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+}
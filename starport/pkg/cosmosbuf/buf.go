@@ -0,0 +1,145 @@
+// Package cosmosbuf wraps the buf CLI (https://buf.build) so that cosmosgen
+// can export and generate code from proto files spread across the Go module
+// cache, instead of shelling out to protoc directly.
+package cosmosbuf
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tendermint/starport/starport/pkg/cmdrunner"
+	"github.com/tendermint/starport/starport/pkg/cmdrunner/step"
+	"golang.org/x/time/rate"
+)
+
+const binaryName = "buf"
+
+// defaultRateLimit is applied when New is called without WithRateLimit, to
+// stay well under buf.build's registry rate limits when generating for many
+// modules in parallel.
+const defaultRateLimit = 10
+
+// Command represents a buf subcommand.
+type Command string
+
+const (
+	CommandExport   Command = "export"
+	CommandGenerate Command = "generate"
+)
+
+// ErrBufNotFound is returned when the buf binary can't be found on PATH.
+var ErrBufNotFound = errors.New("buf binary not found, please install it from https://docs.buf.build/installation")
+
+// Buf is a wrapper around the buf CLI binary.
+type Buf struct {
+	path    string
+	limiter *rate.Limiter
+}
+
+// Option configures a Buf client.
+type Option func(*Buf)
+
+// WithRateLimit bounds outbound buf/BSR calls to qps requests per second,
+// retrying with exponential backoff whenever buf.build responds 429 Too Many
+// Requests.
+func WithRateLimit(qps int) Option {
+	return func(b *Buf) {
+		b.limiter = rate.NewLimiter(rate.Limit(qps), qps)
+	}
+}
+
+// WithBinaryPath makes New use path as the buf binary instead of discovering
+// one on the host's PATH.
+func WithBinaryPath(path string) Option {
+	return func(b *Buf) {
+		b.path = path
+	}
+}
+
+// New returns a Buf client for invoking the buf binary, discovered on the
+// host's PATH unless WithBinaryPath overrides it.
+func New(options ...Option) (Buf, error) {
+	b := Buf{
+		limiter: rate.NewLimiter(rate.Limit(defaultRateLimit), defaultRateLimit),
+	}
+	for _, o := range options {
+		o(&b)
+	}
+
+	if b.path == "" {
+		path, err := exec.LookPath(binaryName)
+		if err != nil {
+			return Buf{}, ErrBufNotFound
+		}
+		b.path = path
+	}
+
+	return b, nil
+}
+
+// Export runs "buf export" against the module located at path, writing its
+// full (including imported) proto tree to output.
+func (b Buf) Export(ctx context.Context, path, output string) error {
+	return b.run(ctx, path, CommandExport, ".", "-o", output)
+}
+
+// Generate runs "buf generate" against the workspace located at path, using
+// the plugin template found at templatePath and writing generated code to
+// output. When paths is non-empty, generation is restricted to those input
+// paths (buf's --path flag) instead of the whole workspace.
+func (b Buf) Generate(ctx context.Context, path, templatePath, output string, paths ...string) error {
+	args := []string{".", "--template", templatePath, "-o", output}
+	for _, p := range paths {
+		args = append(args, "--path", p)
+	}
+	return b.run(ctx, path, CommandGenerate, args...)
+}
+
+// run invokes buf, waiting on the rate limiter beforehand so parallel calls
+// (e.g. across every module in modfile.Require) are spaced out to qps rather
+// than bursting all at once, then retrying with exponential backoff if
+// buf.build still throttles the request with a 429.
+func (b Buf) run(ctx context.Context, workdir string, command Command, args ...string) error {
+	const maxAttempts = 5
+
+	backoff := time.Second
+	var lastErr error
+
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if err := b.limiter.Wait(ctx); err != nil {
+			return err
+		}
+
+		var stderr bytes.Buffer
+		err := cmdrunner.
+			New(cmdrunner.DefaultWorkdir(workdir)).
+			Run(ctx, step.New(
+				step.Exec(b.path, append([]string{string(command)}, args...)...),
+				step.Stderr(&stderr),
+			))
+		if err == nil {
+			return nil
+		}
+		if !isTooManyRequests(stderr.String()) {
+			return err
+		}
+
+		lastErr = err
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+
+	return errors.Wrap(lastErr, "buf.build rate limit exceeded, giving up")
+}
+
+func isTooManyRequests(output string) bool {
+	return strings.Contains(output, "429") || strings.Contains(output, "Too Many Requests")
+}
@@ -1,49 +1,99 @@
 package cosmosgen
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
 
 	"github.com/otiai10/copy"
 	"github.com/pkg/errors"
+	"github.com/tendermint/starport/starport/pkg/binaries"
+	"github.com/tendermint/starport/starport/pkg/cache"
 	"github.com/tendermint/starport/starport/pkg/cmdrunner"
 	"github.com/tendermint/starport/starport/pkg/cmdrunner/step"
+	"github.com/tendermint/starport/starport/pkg/cosmosbuf"
 	"github.com/tendermint/starport/starport/pkg/gomodule"
-	"github.com/tendermint/starport/starport/pkg/nodetime/sta"
-	tsproto "github.com/tendermint/starport/starport/pkg/nodetime/ts-proto"
 	"github.com/tendermint/starport/starport/pkg/protoanalysis"
-	"github.com/tendermint/starport/starport/pkg/protoc"
-	"github.com/tendermint/starport/starport/pkg/protopath"
 	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/sync/errgroup"
 )
 
 var (
-	goOuts = []string{
-		"--gocosmos_out=plugins=interfacetype+grpc,Mgoogle/protobuf/any.proto=github.com/cosmos/cosmos-sdk/codec/types:.",
-		"--grpc-gateway_out=logtostderr=true:.",
-	}
+	sdkProto           = "proto"
+	sdkProtoThirdParty = "third_party/proto"
+)
 
-	tsOut = []string{
-		"--ts_proto_out=.",
-	}
+// bufGenGoPlugins are the buf.gen.yaml plugin entries used to produce
+// the Go server/client code, keyed to the same output flags the old protoc
+// invocation used.
+var bufGenGoPlugins = []bufGenTemplatePlugin{
+	{
+		Plugin: "gocosmos",
+		Out:    ".",
+		Opt: []string{
+			"plugins=interfacetype+grpc",
+			"Mgoogle/protobuf/any.proto=github.com/cosmos/cosmos-sdk/codec/types",
+		},
+	},
+	{
+		Plugin: "grpc-gateway",
+		Out:    ".",
+		Opt:    []string{"logtostderr=true"},
+	},
+}
 
-	openAPIOut = []string{
-		"--openapiv2_out=logtostderr=true,allow_merge=true:.",
-	}
+var bufGenTSPlugins = []bufGenTemplatePlugin{
+	{
+		Plugin: "ts-proto",
+		Out:    ".",
+	},
+}
 
-	sdkImport          = "github.com/cosmos/cosmos-sdk"
-	sdkProto           = "proto"
-	sdkProtoThirdParty = "third_party/proto"
+var bufGenOpenAPIPlugins = []bufGenTemplatePlugin{
+	{
+		Plugin: "openapiv2",
+		Out:    ".",
+		Opt:    []string{"logtostderr=true", "allow_merge=true"},
+	},
+}
 
-	fileTypes = "types"
-)
+// go-pulsar targets google.golang.org/protobuf, not gogoproto, so it must
+// not inherit the gogo Any remapping bufGenGoPlugins applies: pulsar's own
+// google.golang.org/protobuf/types/known/anypb.Any is the correct runtime
+// type for *.pulsar.go output, and remapping it to the gogo codec/types Any
+// would make the generated code reference the wrong Any implementation.
+var bufGenPulsarPlugins = []bufGenTemplatePlugin{
+	{
+		Plugin: "go-pulsar",
+		Out:    ".",
+	},
+}
+
+// pulsarAPIImport is the module whose presence in the app's go.mod signals
+// that it's on Cosmos SDK 0.50+ and wants Pulsar-generated API types
+// alongside the classic gogoproto ones.
+const pulsarAPIImport = "cosmossdk.io/api"
 
 type generateOptions struct {
-	gomodPath string
-	jsOut     func(protoanalysis.Package, string) string
+	gomodPath       string
+	jsOut           func(protoanalysis.Package, string) string
+	openAPIOut      string
+	cache           cache.Cache[[]byte]
+	bufRateLimitQPS int
+	concurrency     int
+	protoPaths      []string
+	pulsarGomodPath string
+	withoutPulsar   bool
 }
 
 // TODO add WithInstall.
@@ -51,7 +101,10 @@ type generateOptions struct {
 // Target adds a new code generation target to Generate.
 type Target func(*generateOptions)
 
-// WithJSGeneration adds JS code generation.
+// WithJSGeneration adds JS code generation. Unlike the Go and OpenAPI
+// passes, this requires a host Node.js toolchain with protoc-gen-ts-proto
+// preinstalled on PATH: ts-proto ships on npm, not as a Go module, so
+// pkg/binaries can't resolve and cache it on a clean machine.
 func WithJSGeneration(out func(pkg protoanalysis.Package, moduleName string) (path string)) Target {
 	return func(o *generateOptions) {
 		o.jsOut = out
@@ -65,6 +118,71 @@ func WithGoGeneration(gomodPath string) Target {
 	}
 }
 
+// WithOpenAPIGeneration adds OpenAPI spec generation, merging the spec of
+// every proto package into a single document written to out.
+func WithOpenAPIGeneration(out string) Target {
+	return func(o *generateOptions) {
+		o.openAPIOut = out
+	}
+}
+
+// WithGenerationCache makes Generate check c before running each buf
+// export/generate step, keyed by a content hash of the proto files and
+// plugin configuration involved, and populate it on success.
+func WithGenerationCache(c cache.Cache[[]byte]) Target {
+	return func(o *generateOptions) {
+		o.cache = c
+	}
+}
+
+// WithBufRateLimit bounds outbound buf/BSR calls to qps requests per second.
+// Defaults to a conservative 10 QPS when not set, since generating for many
+// modules in parallel can otherwise hit buf.build's registry rate limits.
+func WithBufRateLimit(qps int) Target {
+	return func(o *generateOptions) {
+		o.bufRateLimitQPS = qps
+	}
+}
+
+// WithConcurrency bounds how many proto packages are code generated for in
+// parallel. Defaults to runtime.GOMAXPROCS(0) when not set.
+func WithConcurrency(n int) Target {
+	return func(o *generateOptions) {
+		o.concurrency = n
+	}
+}
+
+// WithProtoPaths overrides the relative proto roots looked for within a
+// dependency module when it doesn't declare its own buf workspace/module
+// configuration. Defaults to "proto" and "third_party/proto". Intended to be
+// plumbed from an app's config.yml build.proto.third_party_paths.
+func WithProtoPaths(paths ...string) Target {
+	return func(o *generateOptions) {
+		o.protoPaths = paths
+	}
+}
+
+// WithPulsarGeneration adds Pulsar (cosmossdk.io/api, google.golang.org/protobuf)
+// code generation, copying the *.pulsar.go files generated under gomodPath
+// into the project. It requires WithGoGeneration to also be passed to
+// Generate: Pulsar code is generated as part of the Go generation pass, and
+// is skipped entirely if that pass doesn't run. It's a no-op when the app's
+// go.mod doesn't require cosmossdk.io/api; see WithoutPulsarGeneration to
+// opt out unconditionally.
+func WithPulsarGeneration(gomodPath string) Target {
+	return func(o *generateOptions) {
+		o.pulsarGomodPath = gomodPath
+	}
+}
+
+// WithoutPulsarGeneration unconditionally disables Pulsar generation, even
+// if the app's go.mod requires cosmossdk.io/api.
+func WithoutPulsarGeneration() Target {
+	return func(o *generateOptions) {
+		o.withoutPulsar = true
+	}
+}
+
 // generator generates code for sdk and sdk apps.
 type generator struct {
 	ctx          context.Context
@@ -73,6 +191,13 @@ type generator struct {
 	includePaths []string
 	o            *generateOptions
 	modfile      *modfile.File
+	modCacheDir  string
+
+	// bufOnce guards buf and bufErr, so that every buf call made during one
+	// Generate shares a single client and therefore a single rate limiter.
+	bufOnce sync.Once
+	buf     cosmosbuf.Buf
+	bufErr  error
 }
 
 // Generate generates code from proto app's proto files.
@@ -115,6 +240,12 @@ func Generate(
 		}
 	}
 
+	if g.o.openAPIOut != "" {
+		if err := g.generateOpenAPI(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -134,25 +265,114 @@ func (g *generator) setup() (err error) {
 	}
 
 	// parse the go.mod of the app.
-	g.modfile, err = gomodule.ParseAt(g.projectPath)
+	if g.modfile, err = gomodule.ParseAt(g.projectPath); err != nil {
+		return err
+	}
+
+	// resolve the local module cache directory so indirect dependencies'
+	// proto files (ibc-go, cosmos-proto, wasmd, ...) can be located without
+	// shelling out to 'go list' once per module.
+	var out bytes.Buffer
+	if err := cmdrunner.
+		New(cmdrunner.DefaultWorkdir(g.projectPath)).
+		Run(g.ctx, step.New(step.Exec("go", "env", "GOMODCACHE"), step.Stdout(&out))); err != nil {
+		return err
+	}
+	g.modCacheDir = strings.TrimSpace(out.String())
 
-	return
+	return nil
 }
 
 func (g *generator) generateGo() error {
-	includePaths, err := g.resolveInclude(protopath.NewModule(sdkImport, sdkProto, sdkProtoThirdParty))
+	workspace, cleanup, err := g.buildProtoWorkspace()
 	if err != nil {
 		return err
 	}
+	defer cleanup()
 
-	// created a temporary dir to locate generated code under which later only some of them will be moved to the
-	// app's source code. this also prevents having leftover files in the app's source code or its parent dir -when
-	// command executed directly there- in case of an interrupt.
-	tmp, err := ioutil.TempDir("", "")
+	goOut, err := ioutil.TempDir("", "")
 	if err != nil {
 		return err
 	}
-	defer os.RemoveAll(tmp)
+	defer os.RemoveAll(goOut)
+
+	eg := &errgroup.Group{}
+
+	eg.Go(func() error {
+		return g.bufGenerate(workspace, goOut, bufGenGoPlugins)
+	})
+
+	// the Pulsar pass writes to its own output dir, even though its files
+	// end up interleaved with the classic gogoproto ones once copied into
+	// the project: sharing one dir between the two concurrent bufGenerate
+	// calls above would race buf writing into it and, with a generation
+	// cache configured, have each pass's cache.Put tar up the other's
+	// in-progress output.
+	var pulsarOut string
+	if g.wantsPulsarGeneration() {
+		pulsarOut, err = ioutil.TempDir("", "")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(pulsarOut)
+
+		eg.Go(func() error {
+			return g.bufGenerate(workspace, pulsarOut, bufGenPulsarPlugins)
+		})
+	}
+
+	if err := eg.Wait(); err != nil {
+		return err
+	}
+
+	// move generated code for the app under the relative locations in its source code.
+	generatedPath := filepath.Join(goOut, g.o.gomodPath)
+	if err := copy.Copy(generatedPath, g.projectPath); err != nil {
+		return errors.Wrap(err, "cannot copy path")
+	}
+
+	if pulsarOut == "" {
+		return nil
+	}
+
+	generatedPulsarPath := filepath.Join(pulsarOut, g.o.pulsarGomodPath)
+	return errors.Wrap(copy.Copy(generatedPulsarPath, g.projectPath), "cannot copy path")
+}
+
+// wantsPulsarGeneration reports whether Pulsar code should be generated
+// alongside the classic gogoproto Go code: the caller must have opted in via
+// WithPulsarGeneration, not opted out via WithoutPulsarGeneration, and the
+// app's go.mod must actually require cosmossdk.io/api.
+func (g *generator) wantsPulsarGeneration() bool {
+	if g.o.pulsarGomodPath == "" || g.o.withoutPulsar {
+		return false
+	}
+
+	for _, r := range g.modfile.Require {
+		if r.Mod.Path == pulsarAPIImport {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (g *generator) generateJS() error {
+	workspace, cleanup, err := g.buildProtoWorkspace()
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	tsOut, err := ioutil.TempDir("", "")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tsOut)
+
+	if err := g.bufGenerate(workspace, tsOut, bufGenTSPlugins); err != nil {
+		return err
+	}
 
 	// discover every sdk module.
 	pkgs, err := protoanalysis.DiscoverPackages(g.protoPath)
@@ -160,98 +380,444 @@ func (g *generator) generateGo() error {
 		return err
 	}
 
-	// code generate for each module.
+	// copy each module's generated code into place, in parallel.
+	eg := &errgroup.Group{}
+	eg.SetLimit(g.concurrency())
+
 	for _, pkg := range pkgs {
-		if err := protoc.Generate(g.ctx, tmp, pkg.Path, includePaths, goOuts); err != nil {
-			return err
-		}
+		pkg := pkg
+
+		eg.Go(func() error {
+			var (
+				msp        = strings.Split(pkg.Name, ".")
+				moduleName = msp[len(msp)-1]
+
+				out = g.o.jsOut(pkg, moduleName)
+			)
+
+			rel, err := filepath.Rel(g.protoPath, pkg.Path)
+			if err != nil {
+				return err
+			}
+
+			// ts-proto mirrors the generated files under the proto package's
+			// on-disk directory inside the workspace-wide output dir, which
+			// doesn't always match its dotted package name; move the ones
+			// for this module next to the rest of its generated client code.
+			generatedPath := filepath.Join(tsOut, rel)
+			return errors.Wrap(copy.Copy(generatedPath, out), "cannot copy path")
+		})
 	}
 
-	// move generated code for the app under the relative locations in its source code.
-	generatedPath := filepath.Join(tmp, g.o.gomodPath)
-	err = copy.Copy(generatedPath, g.projectPath)
-	return errors.Wrap(err, "cannot copy path")
+	return eg.Wait()
 }
 
-func (g *generator) generateJS() error {
-	includePaths, err := g.resolveInclude(protopath.NewModule(sdkImport, sdkProto, sdkProtoThirdParty))
+// generateOpenAPI generates an OpenAPI spec per proto package and merges them
+// in-process into a single OpenAPI 2.0 document written to g.o.openAPIOut,
+// deduplicating operationIds and definitions that collide across packages.
+func (g *generator) generateOpenAPI() error {
+	workspace, cleanup, err := g.buildProtoWorkspace()
 	if err != nil {
 		return err
 	}
+	defer cleanup()
+
+	plugins := g.resolvePluginPaths(bufGenOpenAPIPlugins)
 
-	tsprotoPluginPath, err := tsproto.BinaryPath()
+	tplDir, err := ioutil.TempDir("", "")
 	if err != nil {
 		return err
 	}
+	defer os.RemoveAll(tplDir)
+
+	tpl := filepath.Join(tplDir, "buf.gen.yaml")
+	if err := writeBufGenTemplate(tpl, plugins); err != nil {
+		return err
+	}
 
-	// discover every sdk module.
 	pkgs, err := protoanalysis.DiscoverPackages(g.protoPath)
 	if err != nil {
 		return err
 	}
 
-	// code generate for each module.
+	var (
+		doc = newOpenAPIDocument()
+		mu  sync.Mutex
+	)
+
+	eg := &errgroup.Group{}
+	eg.SetLimit(g.concurrency())
+
 	for _, pkg := range pkgs {
-		var (
-			msp        = strings.Split(pkg.Name, ".")
-			moduleName = msp[len(msp)-1]
+		pkg := pkg
+
+		eg.Go(func() error {
+			rel, err := filepath.Rel(g.protoPath, pkg.Path)
+			if err != nil {
+				return err
+			}
+
+			pkgOut, err := ioutil.TempDir("", "")
+			if err != nil {
+				return err
+			}
+			defer os.RemoveAll(pkgOut)
+
+			if err := g.bufGenerateAt(workspace, tpl, pkgOut, rel); err != nil {
+				return err
+			}
+
+			spec := filepath.Join(pkgOut, "apidocs.swagger.json")
+			b, err := ioutil.ReadFile(spec)
+			if err != nil {
+				// modules without any HTTP-annotated RPC don't produce a spec.
+				return nil
+			}
+
+			var pkgDoc openAPIDocument
+			if err := json.Unmarshal(b, &pkgDoc); err != nil {
+				return errors.Wrapf(err, "cannot parse openapi spec of %s", pkg.Name)
+			}
+
+			msp := strings.Split(pkg.Name, ".")
+			moduleName := msp[len(msp)-1]
+
+			mu.Lock()
+			doc.merge(moduleName, pkgDoc)
+			mu.Unlock()
+
+			return nil
+		})
+	}
 
-			out = g.o.jsOut(pkg, moduleName)
-		)
+	if err := eg.Wait(); err != nil {
+		return err
+	}
 
-		prototmp, err := ioutil.TempDir("", "")
-		if err != nil {
-			return err
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return errors.Wrap(ioutil.WriteFile(g.o.openAPIOut, out, 0644), "cannot write openapi spec")
+}
+
+// buildProtoWorkspace assembles a self-contained proto tree for the app by
+// exporting the app's own proto files together with those of every module it
+// requires, direct or indirect, so that e.g. ibc-go, cosmos-proto or wasmd
+// imports resolve even though they're not declared as explicit include paths.
+// The caller is responsible for calling the returned cleanup func.
+func (g *generator) buildProtoWorkspace() (path string, cleanup func(), err error) {
+	workspace, err := ioutil.TempDir("", "")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup = func() { os.RemoveAll(workspace) }
+
+	if err := copy.Copy(g.protoPath, workspace); err != nil {
+		cleanup()
+		return "", nil, errors.Wrap(err, "cannot copy path")
+	}
+
+	// fold in any extra, already-resolved include paths the caller passed to
+	// Generate directly.
+	for _, include := range g.includePaths {
+		if err := copy.Copy(include, workspace); err != nil {
+			cleanup()
+			return "", nil, errors.Wrap(err, "cannot copy path")
 		}
-		defer os.RemoveAll(prototmp)
-
-		// generate ts-proto types for each module.
-		err = protoc.Generate(
-			g.ctx,
-			prototmp,
-			pkg.Path,
-			includePaths,
-			tsOut,
-			protoc.Plugin(tsprotoPluginPath),
-		)
-
-		oaitemp, err := ioutil.TempDir("", "")
-		if err != nil {
-			return err
+	}
+
+	buf, err := g.newBuf()
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
+	// export every required module's proto files, in parallel, each into its
+	// own temp dir, then merge them into the shared workspace under a
+	// mutex-protected copy step.
+	var mu sync.Mutex
+
+	eg := &errgroup.Group{}
+	eg.SetLimit(g.concurrency())
+
+	for _, r := range g.modfile.Require {
+		r := r
+
+		eg.Go(func() error {
+			dir, err := g.moduleCacheDir(r.Mod)
+			if err != nil {
+				return err
+			}
+
+			roots, err := g.discoverProtoRoots(dir)
+			if err != nil {
+				return err
+			}
+
+			for _, src := range roots {
+				if _, err := os.Stat(src); err != nil {
+					// this dependency doesn't ship proto files under this root, skip it.
+					continue
+				}
+
+				exported, exportedCleanup, err := g.bufExport(buf, src)
+				if err != nil {
+					return err
+				}
+
+				mu.Lock()
+				err = copy.Copy(exported, workspace)
+				mu.Unlock()
+				exportedCleanup()
+				if err != nil {
+					return errors.Wrap(err, "cannot copy path")
+				}
+			}
+
+			return nil
+		})
+	}
+
+	if err := eg.Wait(); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
+	return workspace, cleanup, nil
+}
+
+// moduleCacheDir returns the on-disk location of mod inside the local Go
+// module cache, without requiring 'go list' to be invoked for every module.
+func (g *generator) moduleCacheDir(mod module.Version) (string, error) {
+	escapedPath, err := module.EscapePath(mod.Path)
+	if err != nil {
+		return "", err
+	}
+	escapedVersion, err := module.EscapeVersion(mod.Version)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(g.modCacheDir, escapedPath+"@"+escapedVersion), nil
+}
+
+// bufGenerate writes a buf.gen.yaml invoking plugins, of its own, to a
+// private temp dir (so concurrent callers sharing the same workspace can't
+// clobber each other's template) and runs buf generate for workspace,
+// placing generated code under out.
+func (g *generator) bufGenerate(workspace, out string, plugins []bufGenTemplatePlugin) error {
+	plugins = g.resolvePluginPaths(plugins)
+
+	tplDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tplDir)
+
+	tpl := filepath.Join(tplDir, "buf.gen.yaml")
+	if err := writeBufGenTemplate(tpl, plugins); err != nil {
+		return err
+	}
+
+	return g.bufGenerateAt(workspace, tpl, out)
+}
+
+// bufGenerateAt runs buf generate for workspace using the plugin template
+// already written at tplPath, placing generated code under out. When
+// relPaths is given, generation is restricted to those paths (relative to
+// workspace) instead of the whole workspace. Results are served from and
+// populated into g.o.cache when configured.
+func (g *generator) bufGenerateAt(workspace, tplPath, out string, relPaths ...string) error {
+	buf, err := g.newBuf()
+	if err != nil {
+		return err
+	}
+
+	paths := make([]string, len(relPaths))
+	for i, p := range relPaths {
+		paths[i] = filepath.Join(workspace, p)
+	}
+
+	if g.o.cache == nil {
+		return buf.Generate(g.ctx, workspace, tplPath, out, paths...)
+	}
+
+	key, err := g.generationCacheKey(workspace, tplPath, relPaths...)
+	if err != nil {
+		return err
+	}
+
+	if tarball, ok, err := g.o.cache.Get(key); err != nil {
+		return err
+	} else if ok {
+		return untar(tarball, out)
+	}
+
+	if err := buf.Generate(g.ctx, workspace, tplPath, out, paths...); err != nil {
+		return err
+	}
+
+	tarball, err := tarDir(out)
+	if err != nil {
+		return err
+	}
+	return g.o.cache.Put(key, tarball)
+}
+
+// generationCacheKey derives a cache key from the content of every proto
+// file under workspace, the plugin template applied to it, and any
+// restrict-paths generation was scoped to, so the cache is invalidated
+// whenever any of those change. relPaths must be relative to workspace (an
+// absolute path would embed workspace's own temp directory name, a fresh
+// one every run, and defeat caching entirely).
+func (g *generator) generationCacheKey(workspace, tplPath string, relPaths ...string) (string, error) {
+	protoHash, err := hashDir(workspace)
+	if err != nil {
+		return "", err
+	}
+
+	tpl, err := ioutil.ReadFile(tplPath)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	io.WriteString(h, protoHash)
+	h.Write(tpl)
+	for _, p := range relPaths {
+		io.WriteString(h, p)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// bufExport runs buf export for src into a freshly created temp dir and
+// returns its path together with a cleanup func the caller must invoke.
+// Like bufGenerate, the result is served from and populated into g.o.cache
+// when configured, keyed by src's own content since export output depends
+// only on that.
+func (g *generator) bufExport(buf cosmosbuf.Buf, src string) (path string, cleanup func(), err error) {
+	exported, err := ioutil.TempDir("", "")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup = func() { os.RemoveAll(exported) }
+
+	if g.o.cache == nil {
+		if err := buf.Export(g.ctx, src, exported); err != nil {
+			cleanup()
+			return "", nil, errors.Wrapf(err, "cannot export proto files of %s", src)
 		}
-		defer os.RemoveAll(oaitemp)
-
-		// generate OpenAPI spec.
-		err = protoc.Generate(
-			g.ctx,
-			oaitemp,
-			pkg.Path,
-			includePaths,
-			openAPIOut,
-		)
-		if err != nil {
-			return err
+		return exported, cleanup, nil
+	}
+
+	hash, err := hashDir(src)
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	key := "export:" + hash
+
+	if tarball, ok, err := g.o.cache.Get(key); err != nil {
+		cleanup()
+		return "", nil, err
+	} else if ok {
+		if err := untar(tarball, exported); err != nil {
+			cleanup()
+			return "", nil, err
 		}
+		return exported, cleanup, nil
+	}
 
-		// generate the REST client from the OpenAPI spec.
-		var (
-			srcspec = filepath.Join(oaitemp, "apidocs.swagger.json")
-			outjs   = filepath.Join(out, "rest.js")
-		)
-		if err := sta.Generate(g.ctx, outjs, srcspec); err != nil {
-			return err
+	if err := buf.Export(g.ctx, src, exported); err != nil {
+		cleanup()
+		return "", nil, errors.Wrapf(err, "cannot export proto files of %s", src)
+	}
+
+	tarball, err := tarDir(exported)
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	if err := g.o.cache.Put(key, tarball); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
+	return exported, cleanup, nil
+}
+
+// newBuf returns a buf client honoring the rate limit configured on the
+// generator, if any, preferring the pinned buf build resolved through
+// pkg/binaries so a clean machine doesn't need buf preinstalled. Falls back
+// to a buf binary discovered on PATH (cosmosbuf.New's default) when the
+// pinned build can't be resolved, e.g. no network access to fetch it. The
+// client is created on the first call and returned by every later one, so
+// all buf calls of the generator wait on the same rate limiter.
+func (g *generator) newBuf() (cosmosbuf.Buf, error) {
+	g.bufOnce.Do(func() {
+		var options []cosmosbuf.Option
+		if g.o.bufRateLimitQPS != 0 {
+			options = append(options, cosmosbuf.WithRateLimit(g.o.bufRateLimitQPS))
+		}
+
+		if path, err := binaries.Resolve(g.ctx, "buf"); err == nil {
+			options = append(options, cosmosbuf.WithBinaryPath(path))
 		}
+
+		g.buf, g.bufErr = cosmosbuf.New(options...)
+	})
+
+	return g.buf, g.bufErr
+}
+
+// pluginBinaryName maps a buf.gen.yaml plugin name to the pkg/binaries name
+// of the protoc-gen-* binary implementing it. ts-proto has no pkg/binaries
+// spec (it ships on npm, not as a Go module) and is listed here only so
+// resolvePluginPaths' fallback leaves it to buf's own PATH-based resolution.
+var pluginBinaryName = map[string]string{
+	"gocosmos":     "protoc-gen-gocosmos",
+	"grpc-gateway": "protoc-gen-grpc-gateway",
+	"openapiv2":    "protoc-gen-openapiv2",
+	"go-pulsar":    "protoc-gen-go-pulsar",
+	"ts-proto":     "protoc-gen-ts-proto",
+}
+
+// resolvePluginPaths returns plugins with Path set to the pkg/binaries
+// resolved build of each one, so buf invokes them without requiring them
+// preinstalled on PATH. A plugin that can't be resolved this
+// way (no pinned binary known for it, or it couldn't be fetched) is passed
+// through unchanged, falling back to buf's own PATH-based resolution of
+// protoc-gen-<plugin>.
+func (g *generator) resolvePluginPaths(plugins []bufGenTemplatePlugin) []bufGenTemplatePlugin {
+	resolved := make([]bufGenTemplatePlugin, len(plugins))
+
+	for i, p := range plugins {
+		resolved[i] = p
+
+		binary, ok := pluginBinaryName[p.Plugin]
+		if !ok {
+			continue
+		}
+
+		path, err := binaries.Resolve(g.ctx, binary)
+		if err != nil {
+			continue
+		}
+
+		resolved[i].Name = p.Plugin
+		resolved[i].Plugin = ""
+		resolved[i].Path = path
 	}
 
-	return nil
+	return resolved
 }
 
-func (g *generator) resolveInclude(modules ...protopath.Module) (paths []string, err error) {
-	includePaths, err := protopath.ResolveDependencyPaths(g.modfile.Require, modules...)
-	if err != nil {
-		return nil, err
+// concurrency returns how many proto packages should be code generated for
+// in parallel.
+func (g *generator) concurrency() int {
+	if g.o.concurrency > 0 {
+		return g.o.concurrency
 	}
-	includePaths = append([]string{g.protoPath}, includePaths...)
-	includePaths = append(includePaths, g.includePaths...)
-	return includePaths, nil
+	return runtime.GOMAXPROCS(0)
 }
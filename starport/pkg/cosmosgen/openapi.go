@@ -0,0 +1,96 @@
+package cosmosgen
+
+import "encoding/json"
+
+// openAPIDocument is the minimal shape of an OpenAPI 2.0 ("Swagger") document
+// this package cares about merging: paths, definitions and tags. Of the other
+// top-level fields, only info is kept, taken from the first document merged;
+// swagger is always "2.0", and every other field is dropped.
+type openAPIDocument struct {
+	Swagger     string                     `json:"swagger,omitempty"`
+	Info        json.RawMessage            `json:"info,omitempty"`
+	Paths       map[string]json.RawMessage `json:"paths,omitempty"`
+	Definitions map[string]json.RawMessage `json:"definitions,omitempty"`
+	Tags        []json.RawMessage          `json:"tags,omitempty"`
+
+	operationIDs map[string]bool
+	tagNames     map[string]bool
+}
+
+func newOpenAPIDocument() *openAPIDocument {
+	return &openAPIDocument{
+		Swagger:      "2.0",
+		Paths:        map[string]json.RawMessage{},
+		Definitions:  map[string]json.RawMessage{},
+		operationIDs: map[string]bool{},
+		tagNames:     map[string]bool{},
+	}
+}
+
+// merge folds doc, generated for moduleName, into d. Definitions and
+// operationIds colliding with one already merged are prefixed with
+// moduleName so neither is silently dropped. Two packages exposing
+// operations under the same path (e.g. both registering a query at the same
+// URL under different http verbs) have their verbs merged rather than one
+// path clobbering the other, and tags already merged by name are skipped.
+func (d *openAPIDocument) merge(moduleName string, doc openAPIDocument) {
+	if d.Info == nil {
+		d.Info = doc.Info
+	}
+
+	for path, rawMethods := range doc.Paths {
+		var methods map[string]json.RawMessage
+		if err := json.Unmarshal(rawMethods, &methods); err != nil {
+			// not an object of http-verb -> operation, keep as-is.
+			d.Paths[path] = rawMethods
+			continue
+		}
+
+		existing := map[string]json.RawMessage{}
+		if raw, ok := d.Paths[path]; ok {
+			json.Unmarshal(raw, &existing)
+		}
+
+		for verb, rawOp := range methods {
+			var op map[string]interface{}
+			if err := json.Unmarshal(rawOp, &op); err != nil {
+				existing[verb] = rawOp
+				continue
+			}
+
+			if id, ok := op["operationId"].(string); ok {
+				if d.operationIDs[id] {
+					id = moduleName + "_" + id
+				}
+				d.operationIDs[id] = true
+				op["operationId"] = id
+			}
+
+			rawOp, _ = json.Marshal(op)
+			existing[verb] = rawOp
+		}
+
+		merged, _ := json.Marshal(existing)
+		d.Paths[path] = merged
+	}
+
+	for name, def := range doc.Definitions {
+		if _, exists := d.Definitions[name]; exists {
+			name = moduleName + "." + name
+		}
+		d.Definitions[name] = def
+	}
+
+	for _, rawTag := range doc.Tags {
+		var tag struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(rawTag, &tag); err == nil && tag.Name != "" {
+			if d.tagNames[tag.Name] {
+				continue
+			}
+			d.tagNames[tag.Name] = true
+		}
+		d.Tags = append(d.Tags, rawTag)
+	}
+}
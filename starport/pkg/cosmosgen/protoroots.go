@@ -0,0 +1,60 @@
+package cosmosgen
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// bufWorkYAML is the minimal shape of a buf.work.yaml file: a list of
+// directories, relative to it, that make up the workspace.
+type bufWorkYAML struct {
+	Version     string   `yaml:"version"`
+	Directories []string `yaml:"directories"`
+}
+
+// discoverProtoRoots returns the proto include roots to export for the
+// dependency module cached at dir. Dependencies that ship their own buf
+// workspace (buf.work.yaml) or module (buf.yaml) are trusted over the legacy
+// proto/ and third_party/proto/ convention, which newer SDK and app layouts
+// (e.g. the Cosmos SDK 0.47+ proto/{appName}/{moduleName} migration) no
+// longer follow.
+func (g *generator) discoverProtoRoots(dir string) ([]string, error) {
+	if b, err := ioutil.ReadFile(filepath.Join(dir, "buf.work.yaml")); err == nil {
+		var work bufWorkYAML
+		if err := yaml.Unmarshal(b, &work); err != nil {
+			return nil, errors.Wrap(err, "cannot parse buf.work.yaml")
+		}
+
+		roots := make([]string, len(work.Directories))
+		for i, d := range work.Directories {
+			roots[i] = filepath.Join(dir, d)
+		}
+		return roots, nil
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "buf.yaml")); err == nil {
+		return []string{dir}, nil
+	}
+
+	roots := make([]string, len(g.protoPaths()))
+	for i, p := range g.protoPaths() {
+		roots[i] = filepath.Join(dir, p)
+	}
+	return roots, nil
+}
+
+// protoPaths returns the relative proto roots to look for within a
+// dependency module when it doesn't declare its own buf workspace/module
+// configuration: the paths passed to WithProtoPaths, in that order, when
+// set, falling back to the legacy proto/ and third_party/proto/
+// convention otherwise.
+func (g *generator) protoPaths() []string {
+	if len(g.o.protoPaths) > 0 {
+		return g.o.protoPaths
+	}
+	return []string{sdkProto, sdkProtoThirdParty}
+}
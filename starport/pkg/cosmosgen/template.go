@@ -0,0 +1,41 @@
+package cosmosgen
+
+import (
+	"io/ioutil"
+
+	"gopkg.in/yaml.v2"
+)
+
+// bufGenTemplatePlugin is a single entry of a buf.gen.yaml's plugins list.
+// Plugin is resolved as protoc-gen-<Plugin> on PATH unless Path is set, in
+// which case it's invoked directly instead (see resolvePluginPaths).
+type bufGenTemplatePlugin struct {
+	Plugin string   `yaml:"plugin,omitempty"`
+	Name   string   `yaml:"name,omitempty"`
+	Path   string   `yaml:"path,omitempty"`
+	Out    string   `yaml:"out"`
+	Opt    []string `yaml:"opt,omitempty"`
+}
+
+// bufGenTemplate is the minimal shape of a buf.gen.yaml file, as consumed by
+// `buf generate --template`.
+type bufGenTemplate struct {
+	Version string                 `yaml:"version"`
+	Plugins []bufGenTemplatePlugin `yaml:"plugins"`
+}
+
+// writeBufGenTemplate renders a buf.gen.yaml invoking plugins and writes it
+// to path.
+func writeBufGenTemplate(path string, plugins []bufGenTemplatePlugin) error {
+	tpl := bufGenTemplate{
+		Version: "v1",
+		Plugins: plugins,
+	}
+
+	out, err := yaml.Marshal(tpl)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, out, 0644)
+}